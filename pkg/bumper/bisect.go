@@ -0,0 +1,306 @@
+package bumper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	autobumper "k8s.io/test-infra/experiment/autobumper/bumper"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+// skipExitCode mirrors git bisect's convention: a test command exiting with this code means
+// "this candidate can't be tested, try another one" rather than good or bad.
+const skipExitCode = 125
+
+// CandidateResult classifies the outcome of running the test command against a single candidate.
+type CandidateResult int
+
+const (
+	ResultGood CandidateResult = iota
+	ResultBad
+	ResultSkip
+)
+
+// BisectOptions configures a bisection run across a range of candidate image tags.
+type BisectOptions struct {
+	// Candidates are the intermediate image tags to search, ordered oldest (known-good) to
+	// newest (known-bad, i.e. the tag the original bump landed on).
+	Candidates []string
+	// Subfolders is passed through to locate the YAML files whose image references get rewritten.
+	Subfolders []string
+	// ExtraFiles is passed through for non-".yaml" files that should also be considered.
+	ExtraFiles map[string]bool
+	// TestCommand and TestArgs are invoked via bumper.Call after each candidate is applied.
+	TestCommand string
+	TestArgs    []string
+	// Filter restricts which image references candidates are pinned against (DefaultFilter() if
+	// nil), matching NewBumper's convention so a bisection scoped to a custom Filter/set of
+	// RegistryProviders sees the same references the bump that produced it did.
+	Filter Filter
+	// Providers, if non-empty, further restricts pinning to repos covered by one of their
+	// prefixes, same as Bumper.Providers.
+	Providers []RegistryProvider
+}
+
+// BisectResult is the outcome of a completed bisection.
+type BisectResult struct {
+	// CulpritTag is the first candidate classified bad.
+	CulpritTag string
+	// ImagesDiff maps each image reference as it stood before bisection started (repo:tag) to the
+	// bare tag CulpritTag pins it to, the same convention UpdateReferences returns.
+	ImagesDiff map[string]string
+	// TriedTags records every candidate tested, in the order tested, followed by CulpritTag, which
+	// the final re-pin applies whether or not the search itself tested it.
+	TriedTags []string
+}
+
+// Bisect drives a git-bisect-style search over opts.Candidates to find the first bad bump.
+// At each step it rewrites the YAML image references to the midpoint candidate, runs the test
+// command, classifies the exit code as good/bad/skip, and narrows the search range accordingly.
+func Bisect(ctx context.Context, opts BisectOptions) (*BisectResult, error) {
+	if len(opts.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidate tags to bisect")
+	}
+
+	// good and bad are indices into opts.Candidates: Candidates[good] is assumed good (never
+	// itself retested) and Candidates[bad] is assumed bad. The loop only tests indices strictly
+	// between them, so every iteration either raises good or lowers bad and the search always
+	// terminates instead of spinning when bad-good == 1.
+	good, bad := 0, len(opts.Candidates)-1
+	var tried []string
+	skipped := map[int]bool{}
+	// imagesDiff maps each reference as it stood before bisection to the tag it is pinned to now,
+	// composed across every pin so it does not depend on which candidates were tested on the way.
+	imagesDiff := map[string]string{}
+	pin := func(tag string) error {
+		changed, err := pinImageTags(ctx, opts.Subfolders, opts.ExtraFiles, tag, opts.Filter, opts.Providers)
+		for oldRef, newTag := range changed {
+			orig := oldRef
+			for o, cur := range imagesDiff {
+				if o[:strings.LastIndex(o, ":")+1]+cur == oldRef {
+					orig = o
+				}
+			}
+			imagesDiff[orig] = newTag
+			if strings.HasSuffix(orig, ":"+newTag) {
+				delete(imagesDiff, orig)
+			}
+		}
+		return err
+	}
+
+	for bad-good > 1 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		mid, ok := nextTestableIndex(good, bad, skipped)
+		if !ok {
+			return nil, fmt.Errorf("ran out of candidates to bisect after skips")
+		}
+		candidate := opts.Candidates[mid]
+		tried = append(tried, candidate)
+		logrus.Infof("bisect: testing candidate %s (%d remaining)", candidate, bad-good)
+
+		if err := pin(candidate); err != nil {
+			return nil, fmt.Errorf("failed to apply candidate %s: %w", candidate, err)
+		}
+
+		switch runTest(opts) {
+		case ResultGood:
+			good = mid
+		case ResultBad:
+			bad = mid
+		case ResultSkip:
+			logrus.Warnf("bisect: skipping untestable candidate %s", candidate)
+			skipped[mid] = true
+		}
+	}
+
+	// The loop's last pinImageTags call left the tree pinned to whichever candidate was tested
+	// last, which is the midpoint that narrowed bad to its final value, not necessarily culprit
+	// itself (e.g. a Bad test followed by further Good tests leaves the tree pinned to one of
+	// those later, lower candidates). Re-pin to culprit unconditionally so the returned
+	// ImagesDiff and the on-disk tree always agree with CulpritTag.
+	culprit := opts.Candidates[bad]
+	if err := pin(culprit); err != nil {
+		return nil, fmt.Errorf("failed to apply culprit candidate %s: %w", culprit, err)
+	}
+	tried = append(tried, culprit)
+
+	return &BisectResult{
+		CulpritTag: culprit,
+		ImagesDiff: imagesDiff,
+		TriedTags:  tried,
+	}, nil
+}
+
+// nextTestableIndex picks the next candidate to test within the open interval (good, bad),
+// preferring the standard bisection midpoint and walking outward from it to find the nearest
+// index not already in skipped. It reports false once every index in the interval has been
+// skipped, so the caller can give up instead of corrupting the search window.
+func nextTestableIndex(good, bad int, skipped map[int]bool) (int, bool) {
+	mid := good + (bad-good)/2
+	for d := 0; mid-d > good || mid+d < bad; d++ {
+		if lo := mid - d; lo > good && !skipped[lo] {
+			return lo, true
+		}
+		if hi := mid + d; hi < bad && !skipped[hi] {
+			return hi, true
+		}
+	}
+	return 0, false
+}
+
+// pinImageTags rewrites every image reference filter approves (DefaultFilter(), optionally scoped
+// to providers, if filter is nil — the same composition NewBumper uses) under subfolders to tag,
+// returning the map of old image reference to new bare tag. It reuses the same file discovery (a
+// walk for ".yaml" files plus extraFiles), dispatch (forEachPath) and reference pattern
+// (imageRefPattern) as UpdateReferences so
+// bisection's view of "what counts as an image reference" never drifts from the real bumper's;
+// imagebumper.UpdateFile itself resolves new tags from a registry lookup and has no way to pin to
+// an arbitrary historical candidate tag, which is what bisection needs here.
+func pinImageTags(ctx context.Context, subfolders []string, extraFiles map[string]bool, tag string, filter Filter, providers []RegistryProvider) (map[string]string, error) {
+	var paths []string
+	for _, dir := range subfolders {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (!strings.HasSuffix(path, ".yaml") && !extraFiles[path]) {
+				return nil
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filter == nil {
+		filter = DefaultFilter()
+	}
+	if len(providers) > 0 {
+		filter = AllOf{filter, ProviderFilter{Providers: providers}}
+	}
+	var mu sync.Mutex
+	replacements := map[string]string{}
+	err := forEachPath(ctx, paths, ConcurrencyOptions{}, func(_ context.Context, path string) error {
+		fileReplacements, err := pinFile(path, filter, tag)
+		if err != nil {
+			return fmt.Errorf("failed to pin path %s: %w", path, err)
+		}
+		mu.Lock()
+		for k, v := range fileReplacements {
+			replacements[k] = v
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replacements, nil
+}
+
+// pinFile rewrites every image reference in path that filter approves to end in ":tag" instead of
+// whatever tag it currently carries, returning the old reference -> new bare tag map for just this
+// file, matching the convention imagebumper.GetReplacements() (and generateSummary) use.
+func pinFile(path string, filter Filter, tag string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	replacements := map[string]string{}
+	updated := imageRefPattern.ReplaceAllStringFunc(string(raw), func(match string) string {
+		parts := imageRefPattern.FindStringSubmatch(match)
+		ref := ImageRef{Repo: parts[1], Tag: parts[2]}
+		if bump, _ := filter.ShouldBump(path, ref); !bump {
+			return match
+		}
+		oldRef := ref.Repo + ":" + ref.Tag
+		newRef := ref.Repo + ":" + tag
+		if ref.Tag != tag {
+			replacements[oldRef] = tag
+		}
+		return newRef
+	})
+	if updated == string(raw) {
+		return replacements, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(updated), info.Mode()); err != nil {
+		return nil, err
+	}
+	return replacements, nil
+}
+
+// runTest invokes the configured test command and classifies its exit code.
+func runTest(opts BisectOptions) CandidateResult {
+	if err := autobumper.Call(opts.TestCommand, opts.TestArgs...); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == skipExitCode {
+			return ResultSkip
+		}
+		return ResultBad
+	}
+	return ResultGood
+}
+
+// ReportBisectResult posts the bisection outcome as a PR comment through gc and returns it as a
+// JUnit test suite, reusing the junit package already used by jobrunaggregatoranalyzer, so the
+// result plugs into existing test reporting.
+func ReportBisectResult(gc github.Client, org, repo string, pr int, result *BisectResult) (*junit.TestSuite, error) {
+	comment := formatBisectComment(result)
+	if err := gc.CreateComment(org, repo, pr, comment); err != nil {
+		return nil, fmt.Errorf("failed to post bisect comment: %w", err)
+	}
+
+	testCase := &junit.TestCase{
+		Name:      fmt.Sprintf("bisect should isolate a single culprit tag (%d candidate tags applied)", len(result.TriedTags)),
+		SystemOut: comment,
+	}
+	return &junit.TestSuite{
+		Name:      "ImageBisect",
+		TestCases: []*junit.TestCase{testCase},
+	}, nil
+}
+
+func formatBisectComment(result *BisectResult) string {
+	lines := []string{
+		fmt.Sprintf("Bisection found the first bad bump at `%s`.", result.CulpritTag),
+		"",
+		"Image references changed:",
+	}
+	keys := make([]string, 0, len(result.ImagesDiff))
+	for k := range result.ImagesDiff {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// result.ImagesDiff maps old reference (repo:tag) -> new bare tag, the same convention
+	// imagebumper.GetReplacements() uses.
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("- `%s` → `%s`", k, result.ImagesDiff[k]))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,185 @@
+package bumper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNextTestableIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		good    int
+		bad     int
+		skipped map[int]bool
+		want    int
+		wantOk  bool
+	}{
+		{
+			name:   "plain midpoint",
+			good:   0,
+			bad:    10,
+			want:   5,
+			wantOk: true,
+		},
+		{
+			name:    "midpoint skipped, walks outward",
+			good:    0,
+			bad:     10,
+			skipped: map[int]bool{5: true},
+			want:    4,
+			wantOk:  true,
+		},
+		{
+			name:    "every index in the open interval skipped",
+			good:    2,
+			bad:     4,
+			skipped: map[int]bool{3: true},
+			wantOk:  false,
+		},
+		{
+			name:   "single-candidate interval",
+			good:   4,
+			bad:    6,
+			want:   5,
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := nextTestableIndex(tt.good, tt.bad, tt.skipped)
+			if ok != tt.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && got != tt.want {
+				t.Fatalf("index = %d, want %d", got, tt.want)
+			}
+			if ok && (got <= tt.good || got >= tt.bad) {
+				t.Fatalf("index %d is not strictly between good=%d and bad=%d", got, tt.good, tt.bad)
+			}
+		})
+	}
+}
+
+// TestBisectTerminatesAndSkipsCandidates drives a real Bisect run against a temp YAML file and a
+// shell test command, guarding against the bug a16c6a3 fixed: the search must terminate once
+// bad-good == 1 instead of retesting the same midpoint forever, and a skipped candidate must
+// narrow the search (not corrupt good/bad) so bisection still isolates the true culprit.
+func TestBisectTerminatesAndSkipsCandidates(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "deployment.yaml")
+	if err := os.WriteFile(manifest, []byte("image: gcr.io/k8s-prow/hook:v0\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	candidates := []string{"v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", "v8"}
+	const skipTag = "v4"
+	const culpritTag = "v6"
+
+	// testCommand inspects the pinned tag out of manifest and classifies it: skipTag can't be
+	// tested (exit 125), tags at or after culpritTag are bad (exit 1), everything else is good.
+	script := fmt.Sprintf(`
+tag=$(sed -n 's#.*:\(v[0-9]*\)$#\1#p' %q)
+case "$tag" in
+  %s) exit 125 ;;
+esac
+for bad in v6 v7 v8; do
+  if [ "$tag" = "$bad" ]; then exit 1; fi
+done
+exit 0
+`, manifest, skipTag)
+
+	result, err := Bisect(context.Background(), BisectOptions{
+		Candidates:  candidates,
+		Subfolders:  []string{dir},
+		TestCommand: "sh",
+		TestArgs:    []string{"-c", script},
+	})
+	if err != nil {
+		t.Fatalf("Bisect returned error: %v", err)
+	}
+	if result.CulpritTag != culpritTag {
+		t.Fatalf("CulpritTag = %q, want %q", result.CulpritTag, culpritTag)
+	}
+	if len(result.TriedTags) == 0 || len(result.TriedTags) > len(candidates) {
+		t.Fatalf("TriedTags = %v, expected a nonempty subset of %v", result.TriedTags, candidates)
+	}
+	wantImages := map[string]string{"gcr.io/k8s-prow/hook:v0": culpritTag}
+	if !reflect.DeepEqual(result.ImagesDiff, wantImages) {
+		t.Fatalf("ImagesDiff = %v, want %v", result.ImagesDiff, wantImages)
+	}
+}
+
+// TestBisectRepinsToFinalCulprit guards against the bug where Bisect left the on-disk tree (and
+// the returned ImagesDiff) pinned to whatever candidate was tested last, rather than to the final
+// culprit. With candidates v0..v8 and the true culprit at index 4, the natural bisection order is
+// 4 (Bad), 2 (Good), 3 (Good): the loop's last pinImageTags call leaves the tree at v3, one below
+// the actual culprit v4, unless Bisect explicitly re-pins to culprit before returning.
+func TestBisectRepinsToFinalCulprit(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "deployment.yaml")
+	if err := os.WriteFile(manifest, []byte("image: gcr.io/k8s-prow/hook:v0\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	candidates := []string{"v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", "v8"}
+	const culpritTag = "v4"
+
+	script := fmt.Sprintf(`
+tag=$(sed -n 's#.*:\(v[0-9]*\)$#\1#p' %q)
+for bad in v4 v5 v6 v7 v8; do
+  if [ "$tag" = "$bad" ]; then exit 1; fi
+done
+exit 0
+`, manifest)
+
+	result, err := Bisect(context.Background(), BisectOptions{
+		Candidates:  candidates,
+		Subfolders:  []string{dir},
+		TestCommand: "sh",
+		TestArgs:    []string{"-c", script},
+	})
+	if err != nil {
+		t.Fatalf("Bisect returned error: %v", err)
+	}
+	if result.CulpritTag != culpritTag {
+		t.Fatalf("CulpritTag = %q, want %q", result.CulpritTag, culpritTag)
+	}
+	wantImages := map[string]string{"gcr.io/k8s-prow/hook:v0": culpritTag}
+	if !reflect.DeepEqual(result.ImagesDiff, wantImages) {
+		t.Fatalf("ImagesDiff = %v, want %v", result.ImagesDiff, wantImages)
+	}
+
+	onDisk, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	wantOnDisk := "image: gcr.io/k8s-prow/hook:" + culpritTag + "\n"
+	if string(onDisk) != wantOnDisk {
+		t.Fatalf("on-disk manifest = %q, want %q (tree must actually be pinned to CulpritTag)", onDisk, wantOnDisk)
+	}
+}
+
+// TestBisectAllCandidatesSkipped exercises nextTestableIndex's failure path end-to-end: if every
+// candidate between good and bad is untestable, Bisect must give up with an error instead of
+// spinning or silently returning a wrong culprit.
+func TestBisectAllCandidatesSkipped(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "deployment.yaml")
+	if err := os.WriteFile(manifest, []byte("image: gcr.io/k8s-prow/hook:v0\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	_, err := Bisect(context.Background(), BisectOptions{
+		Candidates:  []string{"v0", "v1", "v2"},
+		Subfolders:  []string{dir},
+		TestCommand: "sh",
+		TestArgs:    []string{"-c", "exit 125"},
+	})
+	if err == nil {
+		t.Fatal("expected an error when every candidate is skipped, got nil")
+	}
+}
@@ -0,0 +1,9 @@
+package bumper
+
+// prowPrefix and testImagePrefix mirror the defaults used by
+// k8s.io/test-infra/experiment/autobumper/bumper, so that a caller who doesn't supply a Filter
+// or RegistryProvider gets the same behavior as the vendored bumper.
+const (
+	prowPrefix      = "gcr.io/k8s-prow/"
+	testImagePrefix = "gcr.io/k8s-testimages/"
+)
@@ -0,0 +1,134 @@
+package bumper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ImageRef describes a single image reference discovered while walking YAML manifests.
+type ImageRef struct {
+	Repo string
+	Tag  string
+	// Kind and Name identify the Kubernetes object the reference was found in, e.g. "Deployment"
+	// and "prow-controller-manager".
+	Kind string
+	Name string
+}
+
+// Filter decides whether a given image reference found in a given file should be bumped.
+// Implementations should be safe to reuse across files.
+type Filter interface {
+	// ShouldBump reports whether ref found in path should be rewritten, and if not, why, so
+	// callers can build a per-file audit log of skipped references.
+	ShouldBump(path string, ref ImageRef) (bump bool, reason string)
+}
+
+// RegexFilter is the default Filter: it requires ref.Repo to match Repo, reproducing today's
+// single hardcoded prowPrefix|testImagePrefix regex. It does not look at path: which files are
+// considered at all (".yaml" files plus any extraFiles) is decided by the walk that collects them.
+type RegexFilter struct {
+	Repo *regexp.Regexp
+}
+
+// DefaultFilter reproduces the behavior UpdateReferences had before Filter existed.
+func DefaultFilter() Filter {
+	return &RegexFilter{Repo: regexp.MustCompile(prowPrefix + "|" + testImagePrefix)}
+}
+
+func (f *RegexFilter) ShouldBump(_ string, ref ImageRef) (bool, string) {
+	if !f.Repo.MatchString(ref.Repo) {
+		return false, fmt.Sprintf("repo %q does not match %s", ref.Repo, f.Repo.String())
+	}
+	return true, ""
+}
+
+// PathFilter includes or excludes references by the path of the file they were found in.
+type PathFilter struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+func (f *PathFilter) ShouldBump(path string, _ ImageRef) (bool, string) {
+	if f.Exclude != nil && f.Exclude.MatchString(path) {
+		return false, fmt.Sprintf("path %q matches exclude pattern %s", path, f.Exclude.String())
+	}
+	if f.Include != nil && !f.Include.MatchString(path) {
+		return false, fmt.Sprintf("path %q does not match include pattern %s", path, f.Include.String())
+	}
+	return true, ""
+}
+
+// TagFilter includes only references whose current image tag matches Pattern; it has no exclude
+// pattern.
+type TagFilter struct {
+	Pattern *regexp.Regexp
+}
+
+func (f *TagFilter) ShouldBump(_ string, ref ImageRef) (bool, string) {
+	if !f.Pattern.MatchString(ref.Tag) {
+		return false, fmt.Sprintf("tag %q does not match %s", ref.Tag, f.Pattern.String())
+	}
+	return true, ""
+}
+
+// KindFilter includes or excludes references by the Kubernetes kind of the object they were
+// found in, e.g. restricting bumps to Deployments while excluding CronJobs.
+type KindFilter struct {
+	IncludeKinds sets.String
+	ExcludeKinds sets.String
+}
+
+func (f *KindFilter) ShouldBump(_ string, ref ImageRef) (bool, string) {
+	if f.ExcludeKinds.Len() > 0 && f.ExcludeKinds.Has(ref.Kind) {
+		return false, fmt.Sprintf("kind %q is excluded", ref.Kind)
+	}
+	if f.IncludeKinds.Len() > 0 && !f.IncludeKinds.Has(ref.Kind) {
+		return false, fmt.Sprintf("kind %q is not in the include list", ref.Kind)
+	}
+	return true, ""
+}
+
+// ProviderFilter restricts bumping to repos covered by one of the given RegistryProviders'
+// prefixes, so that registering a provider also scopes which refs UpdateReferences will touch.
+type ProviderFilter struct {
+	Providers []RegistryProvider
+}
+
+func (f ProviderFilter) ShouldBump(_ string, ref ImageRef) (bool, string) {
+	for _, p := range f.Providers {
+		if strings.HasPrefix(ref.Repo, p.Prefix()) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("repo %q does not match any configured RegistryProvider prefix", ref.Repo)
+}
+
+// AllOf composes filters, requiring every one of them to allow the bump.
+type AllOf []Filter
+
+func (fs AllOf) ShouldBump(path string, ref ImageRef) (bool, string) {
+	for _, f := range fs {
+		if bump, reason := f.ShouldBump(path, ref); !bump {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+// AnyOf composes filters, requiring at least one of them to allow the bump.
+type AnyOf []Filter
+
+func (fs AnyOf) ShouldBump(path string, ref ImageRef) (bool, string) {
+	var reasons []string
+	for _, f := range fs {
+		if bump, reason := f.ShouldBump(path, ref); bump {
+			return true, ""
+		} else {
+			reasons = append(reasons, reason)
+		}
+	}
+	return false, strings.Join(reasons, "; ")
+}
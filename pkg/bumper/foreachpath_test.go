@@ -0,0 +1,134 @@
+package bumper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestForEachPathAggregatesAllResults drives many more paths than workers and asserts every path
+// is processed exactly once. Run with -race: this is the aggregation chunk0-3 originally got wrong
+// by calling imagebumper.UpdateFile (a package-level map write) from every worker with no lock.
+func TestForEachPathAggregatesAllResults(t *testing.T) {
+	var paths []string
+	for i := 0; i < 50; i++ {
+		paths = append(paths, fmt.Sprintf("path-%d", i))
+	}
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	err := forEachPath(context.Background(), paths, ConcurrencyOptions{MaxWorkers: 4}, func(_ context.Context, path string) error {
+		mu.Lock()
+		seen[path]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachPath returned error: %v", err)
+	}
+	if len(seen) != len(paths) {
+		t.Fatalf("processed %d distinct paths, want %d", len(seen), len(paths))
+	}
+	for path, count := range seen {
+		if count != 1 {
+			t.Errorf("path %s processed %d times, want 1", path, count)
+		}
+	}
+}
+
+// TestForEachPathMaxWorkersBound asserts no more than MaxWorkers invocations of fn run
+// concurrently.
+func TestForEachPathMaxWorkersBound(t *testing.T) {
+	const maxWorkers = 3
+	var paths []string
+	for i := 0; i < 20; i++ {
+		paths = append(paths, fmt.Sprintf("path-%d", i))
+	}
+
+	var inFlight, maxObserved int64
+	err := forEachPath(context.Background(), paths, ConcurrencyOptions{MaxWorkers: maxWorkers}, func(_ context.Context, _ string) error {
+		cur := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt64(&maxObserved)
+			if cur <= observed || atomic.CompareAndSwapInt64(&maxObserved, observed, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachPath returned error: %v", err)
+	}
+	if maxObserved > maxWorkers {
+		t.Fatalf("observed %d concurrent invocations, want <= %d", maxObserved, maxWorkers)
+	}
+}
+
+// TestForEachPathAggregatesErrors asserts that without StopOnError every path is still attempted
+// and every error is returned, aggregated.
+func TestForEachPathAggregatesErrors(t *testing.T) {
+	paths := []string{"ok-1", "bad-1", "ok-2", "bad-2"}
+
+	var mu sync.Mutex
+	var processed []string
+	err := forEachPath(context.Background(), paths, ConcurrencyOptions{MaxWorkers: 1}, func(_ context.Context, path string) error {
+		mu.Lock()
+		processed = append(processed, path)
+		mu.Unlock()
+		if path == "bad-1" || path == "bad-2" {
+			return fmt.Errorf("failed on %s", path)
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an aggregated error, got nil")
+	}
+	sort.Strings(processed)
+	want := []string{"bad-1", "bad-2", "ok-1", "ok-2"}
+	if len(processed) != len(want) {
+		t.Fatalf("processed %v, want every path attempted: %v", processed, want)
+	}
+	for i := range want {
+		if processed[i] != want[i] {
+			t.Fatalf("processed %v, want %v", processed, want)
+		}
+	}
+}
+
+// TestForEachPathStopOnError asserts that the first error cancels in-flight and queued work, so a
+// StopOnError run doesn't attempt every path the way TestForEachPathAggregatesErrors does without
+// it. The feeder and the failing worker race to observe cancellation, so some of the trailing
+// paths may still start before it takes effect; what must hold is that at least one is skipped.
+func TestForEachPathStopOnError(t *testing.T) {
+	paths := []string{"fails-first"}
+	for i := 0; i < 50; i++ {
+		paths = append(paths, fmt.Sprintf("trailing-%d", i))
+	}
+
+	var mu sync.Mutex
+	var processed []string
+	err := forEachPath(context.Background(), paths, ConcurrencyOptions{MaxWorkers: 1, StopOnError: true}, func(_ context.Context, path string) error {
+		mu.Lock()
+		processed = append(processed, path)
+		mu.Unlock()
+		if path == "fails-first" {
+			return fmt.Errorf("failed on %s", path)
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if len(processed) == 0 || processed[0] != "fails-first" {
+		t.Fatalf("processed = %v, want fails-first to run first", processed)
+	}
+	if len(processed) == len(paths) {
+		t.Fatalf("processed every path (%d), want StopOnError to cancel queued work after the first failure", len(processed))
+	}
+}
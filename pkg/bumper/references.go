@@ -0,0 +1,413 @@
+package bumper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	imagebumper "k8s.io/test-infra/experiment/image-bumper/bumper"
+)
+
+// imageRefPattern extracts repo:tag pairs out of raw YAML text, matching anywhere a reference can
+// appear (image:, command/args entries, env values, ...), the same way the prefix-anchored regex
+// the old single-registry walker used did. The repo must look like "domain.tld[:port]/path" so it
+// doesn't false-positive on unrelated "word:word" text; it deliberately does not try to be a full
+// YAML image-reference parser, which is why rewriteImageRefsPerDocument below supplies the
+// Kind/Name context.
+var imageRefPattern = regexp.MustCompile(`((?:[a-zA-Z0-9][a-zA-Z0-9-]*\.)+[a-zA-Z]{2,}(?::[0-9]+)?/[a-zA-Z0-9._/-]+):([a-zA-Z0-9_.-]+)`)
+
+// yamlDocSeparator splits a multi-document YAML file the same way the YAML spec does, so each
+// document can be decoded (for its own Kind/Name) and scanned (for its own image refs)
+// independently.
+var yamlDocSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// imagebumperMu serializes access to imagebumper.UpdateFile, which records every replacement it
+// makes in a package-level map rather than returning it directly. Without this, workers calling
+// UpdateFile concurrently race on that map (up to and including "fatal error: concurrent map
+// writes"); GetReplacements() is only safe to read once every worker calling UpdateFile has
+// finished, which is why it's read after forEachPath returns rather than per-worker.
+var imagebumperMu sync.Mutex
+
+// SkippedRef records why a discovered image reference was not bumped, for a per-file audit log.
+type SkippedRef struct {
+	Path   string
+	Ref    ImageRef
+	Reason string
+}
+
+// ConcurrencyOptions bounds how many files a Bumper rewrites at once.
+type ConcurrencyOptions struct {
+	// MaxWorkers is the number of files processed in parallel. Zero means GOMAXPROCS.
+	MaxWorkers int
+	// StopOnError cancels any in-flight and not-yet-started work as soon as one file fails,
+	// instead of processing every file regardless of earlier failures.
+	StopOnError bool
+}
+
+// Bumper rewrites image references across a tree of YAML files with bounded concurrency.
+type Bumper struct {
+	Filter Filter
+	// Providers, if non-empty, restricts bumping to repos covered by one of their prefixes (in
+	// addition to Filter) and, for any provider that also implements TagLister, pins matching
+	// refs directly to the newest tag it lists instead of falling through to imagebumper's own
+	// gcr.io/quay.io-specific tag resolution.
+	Providers   []RegistryProvider
+	Concurrency ConcurrencyOptions
+}
+
+// NewBumper constructs a Bumper with the given filter (DefaultFilter() if nil), RegistryProviders
+// and concurrency options (GOMAXPROCS workers, stop-on-error off, if the zero value is given).
+func NewBumper(filter Filter, providers []RegistryProvider, concurrency ConcurrencyOptions) *Bumper {
+	if filter == nil {
+		filter = DefaultFilter()
+	}
+	if len(providers) > 0 {
+		filter = AllOf{filter, ProviderFilter{Providers: providers}}
+	}
+	return &Bumper{Filter: filter, Providers: providers, Concurrency: concurrency}
+}
+
+// UpdateReferences walks subfolders (and any extraFiles) to collect the matching paths, then
+// dispatches them to a bounded pool of workers that each parse and rewrite that file's image
+// references. It returns the map of old image reference (repo:tag) to new bare tag, plus the list of
+// references that were skipped and why. A nil filter reproduces the previous
+// prowPrefix|testImagePrefix behavior.
+func UpdateReferences(subfolders []string, extraFiles map[string]bool, filter Filter, providers []RegistryProvider) (map[string]string, []SkippedRef, error) {
+	return NewBumper(filter, providers, ConcurrencyOptions{}).UpdateReferences(context.Background(), subfolders, extraFiles)
+}
+
+// UpdateReferences is the concurrent counterpart of the package-level UpdateReferences: it
+// collects the candidate paths up front, resolves a tag for every provider capable of listing its
+// own tags, then dispatches the paths to b.Concurrency.MaxWorkers workers (GOMAXPROCS by default),
+// aggregating each worker's replacements and skipped references under a mutex before returning the
+// merged result.
+func (b *Bumper) UpdateReferences(ctx context.Context, subfolders []string, extraFiles map[string]bool) (map[string]string, []SkippedRef, error) {
+	logrus.Info("Bumping image references...")
+
+	var paths []string
+	for _, dir := range subfolders {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (!strings.HasSuffix(path, ".yaml") && !extraFiles[path]) {
+				return nil
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	providerTags, err := resolveProviderTags(ctx, b.Providers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mu sync.Mutex
+	var skipped []SkippedRef
+	replacements := map[string]string{}
+	err = forEachPath(ctx, paths, b.Concurrency, func(ctx context.Context, path string) error {
+		fileSkips, fileReplacements, err := updateFileReferences(path, b.Filter, providerTags)
+		if err != nil {
+			return fmt.Errorf("failed to update path %s: %w", path, err)
+		}
+		mu.Lock()
+		skipped = append(skipped, fileSkips...)
+		for oldRef, newRef := range fileReplacements {
+			replacements[oldRef] = newRef
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for oldRef, newRef := range imagebumper.GetReplacements() {
+		replacements[oldRef] = newRef
+	}
+	return replacements, skipped, nil
+}
+
+// resolveProviderTags asks every provider that implements TagLister for its currently published
+// tags and resolves the one actually built most recently, keyed by the provider's prefix.
+// Providers that don't implement TagLister (e.g. gcrProvider, quayProvider) are left out, so their
+// refs keep falling through to imagebumper.UpdateFile's own tag resolution.
+func resolveProviderTags(ctx context.Context, providers []RegistryProvider) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, provider := range providers {
+		lister, ok := provider.(TagLister)
+		if !ok {
+			continue
+		}
+		available, err := lister.ListTags(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags for %s: %w", provider.Prefix(), err)
+		}
+		if len(available) == 0 {
+			continue
+		}
+		newest, err := newestTag(ctx, lister, available)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve newest tag for %s: %w", provider.Prefix(), err)
+		}
+		tags[provider.Prefix()] = newest
+	}
+	return tags, nil
+}
+
+// newestTag returns whichever of available was built most recently, per lister.TagCreated. Tags
+// for these providers are git SHAs or un-padded semver, neither of which sorts lexicographically
+// by age, so this resolves recency from the registry instead of guessing from the tag string.
+func newestTag(ctx context.Context, lister TagLister, available []string) (string, error) {
+	var newest string
+	var newestAt time.Time
+	for _, tag := range available {
+		created, err := lister.TagCreated(ctx, tag)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve creation time for tag %s: %w", tag, err)
+		}
+		if newest == "" || created.After(newestAt) {
+			newest, newestAt = tag, created
+		}
+	}
+	return newest, nil
+}
+
+// forEachPath dispatches fn over paths using opts.MaxWorkers workers (GOMAXPROCS if unset),
+// similar to dskit's ForEachJob. If opts.StopOnError is set, the first error cancels ctx so
+// in-flight and queued work stop early; otherwise every path is attempted and the errors are
+// aggregated.
+func forEachPath(ctx context.Context, paths []string, opts ConcurrencyOptions, fn func(ctx context.Context, path string) error) error {
+	workers := opts.MaxWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	errCh := make(chan error, len(paths))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				if err := fn(ctx, path); err != nil {
+					errCh <- err
+					if opts.StopOnError {
+						cancel()
+					}
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- path:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// updateFileReferences rewrites path's image references in place. Every reference is evaluated and
+// rewritten at the granularity of its own match within its own YAML document (the same granularity
+// Filter already reasons about via ImageRef.Kind/Name), so approving one reference can never also
+// rewrite a different, filter-excluded reference that happens to share the same repo, or even the
+// same repo:tag, elsewhere in the file. References providerTags doesn't cover fall through to
+// imagebumper, resolved in isolation per reference via resolveImagebumperTag rather than by letting
+// imagebumper.UpdateFile loose on the whole file.
+func updateFileReferences(path string, filter Filter, providerTags map[string]string) ([]SkippedRef, map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []SkippedRef
+	replacements := map[string]string{}
+	resolved := map[string]string{}
+	var rewriteErr error
+
+	updated, err := rewriteImageRefsPerDocument(string(raw), func(doc docContext, repo, tag string) string {
+		if rewriteErr != nil {
+			return repo + ":" + tag
+		}
+
+		ref := ImageRef{Repo: repo, Tag: tag, Kind: doc.Kind, Name: doc.Name}
+		bump, reason := filter.ShouldBump(path, ref)
+		if !bump {
+			skipped = append(skipped, SkippedRef{Path: path, Ref: ref, Reason: reason})
+			return repo + ":" + tag
+		}
+
+		newTag, ok := providerTagFor(repo, providerTags)
+		if !ok {
+			cacheKey := repo + ":" + tag
+			newTag, ok = resolved[cacheKey]
+			if !ok {
+				var err error
+				newTag, err = resolveImagebumperTag(repo, tag)
+				if err != nil {
+					rewriteErr = fmt.Errorf("failed to resolve a new tag for %s in %s: %w", repo, path, err)
+					return repo + ":" + tag
+				}
+				resolved[cacheKey] = newTag
+			}
+			if newTag == "" {
+				return repo + ":" + tag
+			}
+		}
+		if newTag == tag {
+			return repo + ":" + tag
+		}
+		replacements[repo+":"+tag] = newTag
+		return repo + ":" + newTag
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if rewriteErr != nil {
+		return nil, nil, rewriteErr
+	}
+	if updated == string(raw) {
+		return skipped, replacements, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(path, []byte(updated), info.Mode()); err != nil {
+		return nil, nil, err
+	}
+	return skipped, replacements, nil
+}
+
+// resolveImagebumperTag asks imagebumper.UpdateFile for the newest tag of repo by running it
+// against an isolated scratch file containing nothing but a single "repo:tag" reference, so its
+// file-wide rewrite can never touch any occurrence of repo other than this one. It returns "" if
+// imagebumper left the reference unchanged, which covers both "nothing newer to bump to" and any
+// error, which (matching UpdateReferences' previous behavior of only logging such errors) is logged
+// rather than failing the whole file.
+func resolveImagebumperTag(repo, tag string) (string, error) {
+	scratch, err := os.CreateTemp("", "bumper-ref-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	scratchPath := scratch.Name()
+	defer os.Remove(scratchPath)
+
+	if _, err := scratch.WriteString(repo + ":" + tag + "\n"); err != nil {
+		scratch.Close()
+		return "", err
+	}
+	if err := scratch.Close(); err != nil {
+		return "", err
+	}
+
+	imagebumperMu.Lock()
+	err = imagebumper.UpdateFile(scratchPath, regexp.MustCompile(regexp.QuoteMeta(repo)+":"))
+	imagebumperMu.Unlock()
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to resolve a new tag for %s.", repo)
+		return "", nil
+	}
+
+	updated, err := os.ReadFile(scratchPath)
+	if err != nil {
+		return "", err
+	}
+	match := imageRefPattern.FindStringSubmatch(string(updated))
+	if match == nil || match[2] == tag {
+		return "", nil
+	}
+	return match[2], nil
+}
+
+// providerTagFor returns the tag resolveProviderTags picked for whichever provider prefix covers
+// repo, if any.
+func providerTagFor(repo string, providerTags map[string]string) (string, bool) {
+	for prefix, tag := range providerTags {
+		if strings.HasPrefix(repo, prefix) {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// manifest is the subset of a Kubernetes object's fields UpdateReferences needs in order to let a
+// Filter match on kind/name.
+type manifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+}
+
+// docContext carries the Kind/Name of whichever YAML document an image reference was found in, the
+// same context a Filter sees via ImageRef.Kind/Name.
+type docContext struct {
+	Kind, Name string
+}
+
+// rewriteImageRefsPerDocument splits content into YAML documents the same way yamlDocSeparator
+// always has, and for every repo:tag match within each document calls rewrite to get its
+// replacement text. Documents and the original "---" separators between them are reassembled
+// byte-for-byte around the rewritten matches, so rewrite is called, and decides independently, once
+// per occurrence rather than once per distinct repo: two documents (or two containers in the same
+// document) referencing the same repo:tag never share a single rewrite decision.
+func rewriteImageRefsPerDocument(content string, rewrite func(doc docContext, repo, tag string) string) (string, error) {
+	var b strings.Builder
+	writeDoc := func(doc string) error {
+		var m manifest
+		if err := yaml.Unmarshal([]byte(doc), &m); err != nil {
+			return err
+		}
+		ctx := docContext{Kind: m.Kind, Name: m.Metadata.Name}
+		b.WriteString(imageRefPattern.ReplaceAllStringFunc(doc, func(match string) string {
+			parts := imageRefPattern.FindStringSubmatch(match)
+			return rewrite(ctx, parts[1], parts[2])
+		}))
+		return nil
+	}
+
+	start := 0
+	for _, sep := range yamlDocSeparator.FindAllStringIndex(content, -1) {
+		if err := writeDoc(content[start:sep[0]]); err != nil {
+			return "", err
+		}
+		b.WriteString(content[sep[0]:sep[1]])
+		start = sep[1]
+	}
+	if err := writeDoc(content[start:]); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
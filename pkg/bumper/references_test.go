@@ -0,0 +1,85 @@
+package bumper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TestUpdateFileReferencesExcludesSharedRepoTag guards against the bug where rewriting an approved
+// reference also rewrote a filter-excluded reference that happened to share the same repo:tag
+// elsewhere in the file: a Deployment and a CronJob here both reference the exact same sidecar
+// image, and a KindFilter excludes CronJob. Only the Deployment's references may change.
+func TestUpdateFileReferencesExcludesSharedRepoTag(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.yaml")
+	const original = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - image: registry.example.com/team/app:v1
+      - image: registry.example.com/team/sidecar:v1
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: my-cron
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - image: registry.example.com/team/sidecar:v1
+`
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	filter := &KindFilter{ExcludeKinds: sets.NewString("CronJob")}
+	providerTags := map[string]string{"registry.example.com/team": "v2"}
+
+	skipped, replacements, err := updateFileReferences(path, filter, providerTags)
+	if err != nil {
+		t.Fatalf("updateFileReferences returned error: %v", err)
+	}
+
+	if len(skipped) != 1 || skipped[0].Ref.Kind != "CronJob" {
+		t.Fatalf("skipped = %+v, want exactly the CronJob's reference", skipped)
+	}
+
+	wantReplacements := map[string]string{
+		"registry.example.com/team/app:v1":     "v2",
+		"registry.example.com/team/sidecar:v1": "v2",
+	}
+	if len(replacements) != len(wantReplacements) {
+		t.Fatalf("replacements = %v, want %v", replacements, wantReplacements)
+	}
+	for k, v := range wantReplacements {
+		if replacements[k] != v {
+			t.Fatalf("replacements = %v, want %v", replacements, wantReplacements)
+		}
+	}
+
+	updated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	content := string(updated)
+	if strings.Count(content, "team/app:v2") != 1 {
+		t.Errorf("Deployment's app image was not bumped in:\n%s", content)
+	}
+	if strings.Count(content, "team/sidecar:v2") != 1 {
+		t.Errorf("Deployment's sidecar image was not bumped in:\n%s", content)
+	}
+	if strings.Count(content, "team/sidecar:v1") != 1 {
+		t.Errorf("CronJob's sidecar image (excluded by KindFilter) was rewritten, want it untouched:\n%s", content)
+	}
+}
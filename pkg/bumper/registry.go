@@ -0,0 +1,256 @@
+package bumper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	imagebumper "k8s.io/test-infra/experiment/image-bumper/bumper"
+)
+
+// RegistryProvider abstracts the parts of bumper that used to assume gcr.io/k8s-prow layout, so
+// the same machinery can drive bumps against any image registry this repo cares about.
+type RegistryProvider interface {
+	// Prefix is the image repo prefix this provider is responsible for, e.g. "gcr.io/k8s-prow/".
+	Prefix() string
+	// SourceRepoURL is the source repo whose commits the image tags correspond to, used to build
+	// compare URLs.
+	SourceRepoURL() string
+	// ParseTag splits an image tag into the date, commit and variant it was built from.
+	ParseTag(tag string) (date, commit, variant string, err error)
+	// CompareURL returns a link comparing oldCommit and newCommit in SourceRepoURL.
+	CompareURL(oldCommit, newCommit string) string
+}
+
+// gcrProvider is the upstream Prow registry, reproducing bumper's previous hardcoded behavior.
+type gcrProvider struct {
+	prefix  string
+	repoURL string
+}
+
+// NewGCRProvider returns the RegistryProvider for gcr.io/k8s-prow, the upstream Prow images.
+func NewGCRProvider() RegistryProvider {
+	return gcrProvider{prefix: prowPrefix, repoURL: "https://github.com/kubernetes/test-infra"}
+}
+
+// NewGCRTestImageProvider returns the RegistryProvider for gcr.io/k8s-testimages.
+func NewGCRTestImageProvider() RegistryProvider {
+	return gcrProvider{prefix: testImagePrefix, repoURL: "https://github.com/kubernetes/test-infra"}
+}
+
+func (p gcrProvider) Prefix() string        { return p.prefix }
+func (p gcrProvider) SourceRepoURL() string { return p.repoURL }
+
+func (p gcrProvider) ParseTag(tag string) (string, string, string, error) {
+	date, commit, variant := imagebumper.DeconstructTag(tag)
+	return date, commit, variant, nil
+}
+
+func (p gcrProvider) CompareURL(oldCommit, newCommit string) string {
+	return fmt.Sprintf("%s/compare/%s...%s", p.repoURL, oldCommit, newCommit)
+}
+
+// quayProvider targets quay.io repos, used heavily by OpenShift/ci-tools for release-payload
+// images. Quay tags for these images are plain git commit SHAs rather than Prow's
+// "vYYYYMMDD-commit[-variant]" scheme, so there is no date/variant to extract.
+type quayProvider struct {
+	repo    string
+	repoURL string
+}
+
+// NewQuayProvider returns the RegistryProvider for a quay.io/<repo>, whose compare links point at
+// sourceRepoURL.
+func NewQuayProvider(repo, sourceRepoURL string) RegistryProvider {
+	return quayProvider{repo: repo, repoURL: sourceRepoURL}
+}
+
+func (p quayProvider) Prefix() string        { return "quay.io/" + p.repo + "/" }
+func (p quayProvider) SourceRepoURL() string { return p.repoURL }
+
+func (p quayProvider) ParseTag(tag string) (string, string, string, error) {
+	return "", tag, "", nil
+}
+
+func (p quayProvider) CompareURL(oldCommit, newCommit string) string {
+	return fmt.Sprintf("%s/compare/%s...%s", p.repoURL, oldCommit, newCommit)
+}
+
+// TagLister is implemented by RegistryProviders that can enumerate the tags currently published
+// for their repo. UpdateReferences uses this to pin matching refs directly to the newest tag
+// instead of falling through to imagebumper.UpdateFile's own gcr.io/quay.io-specific resolution.
+// "Newest" is resolved from TagCreated rather than assumed from ListTags' ordering, since these
+// registries use git SHAs or un-padded semver, neither of which sorts lexicographically by age.
+type TagLister interface {
+	ListTags(ctx context.Context) ([]string, error)
+	// TagCreated returns when the image referenced by tag was built, so callers can pick the
+	// actual newest tag instead of guessing from the tag string.
+	TagCreated(ctx context.Context, tag string) (time.Time, error)
+}
+
+// ociProvider targets any registry implementing the distribution v2 HTTP API, for registries that
+// are neither gcr.io nor quay.io.
+type ociProvider struct {
+	registryURL string
+	repo        string
+	repoURL     string
+	client      *http.Client
+}
+
+// NewOCIProvider returns a RegistryProvider that queries registryURL's distribution v2 API (e.g.
+// https://myregistry.example.com) for tags of repo, comparing commits against sourceRepoURL.
+func NewOCIProvider(registryURL, repo, sourceRepoURL string) RegistryProvider {
+	return &ociProvider{registryURL: strings.TrimSuffix(registryURL, "/"), repo: repo, repoURL: sourceRepoURL, client: http.DefaultClient}
+}
+
+// Prefix is the image repository this provider lists tags for: registryURL without its scheme,
+// followed by repo.
+func (p *ociProvider) Prefix() string {
+	return strings.TrimPrefix(strings.TrimPrefix(p.registryURL, "https://"), "http://") + "/" + p.repo
+}
+
+func (p *ociProvider) SourceRepoURL() string { return p.repoURL }
+
+func (p *ociProvider) ParseTag(tag string) (string, string, string, error) {
+	return "", tag, "", nil
+}
+
+func (p *ociProvider) CompareURL(oldCommit, newCommit string) string {
+	return fmt.Sprintf("%s/compare/%s...%s", p.repoURL, oldCommit, newCommit)
+}
+
+// ListTags queries the distribution v2 API's tags/list endpoint for every tag currently published
+// for this provider's repo, satisfying TagLister so UpdateReferences can pin to it directly.
+func (p *ociProvider) ListTags(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/v2/%s/tags/list", p.registryURL, p.repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags for %s: %w", p.repo, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list tags for %s: unexpected status %s", p.repo, resp.Status)
+	}
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode tags/list response for %s: %w", p.repo, err)
+	}
+	return body.Tags, nil
+}
+
+// manifestAcceptHeader lists every manifest content type ociProvider can read: single-arch Docker
+// v2 / OCI image manifests (which carry a config digest directly), and the multi-arch manifest
+// list/image index schemas (which don't — configDigest instead picks one platform's entry and
+// recurses into it). Distribution registries negotiate the response shape via Accept rather than
+// content sniffing, so all four have to be offered up front.
+const manifestAcceptHeader = "application/vnd.docker.distribution.manifest.v2+json, application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.oci.image.index.v1+json"
+
+// preferredManifestListArch is the platform configDigest picks out of a multi-arch manifest
+// list/index, matching the architecture this repo's own tooling runs on.
+const preferredManifestListArch = "amd64"
+
+// TagCreated resolves tag's build time from its image config blob's "created" field, the same
+// place `docker inspect`/`crane config` read it from, so recency can be compared without assuming
+// anything about how the registry's tags are named.
+func (p *ociProvider) TagCreated(ctx context.Context, tag string) (time.Time, error) {
+	configDigest, err := p.configDigest(ctx, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", p.registryURL, p.repo, configDigest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch config blob %s for %s:%s: %w", configDigest, p.repo, tag, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("failed to fetch config blob %s for %s:%s: unexpected status %s", configDigest, p.repo, tag, resp.Status)
+	}
+
+	var config struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode config blob %s for %s:%s: %w", configDigest, p.repo, tag, err)
+	}
+	return config.Created, nil
+}
+
+// configDigest fetches tag's manifest and returns the digest of the image config blob it points
+// at, the indirection the distribution v2 API requires to get from a tag to its build time. tag is
+// just the first reference requested: if it resolves to a multi-arch manifest list/index rather
+// than a single-arch manifest (exactly what OpenShift release-payload images publish, this
+// provider's primary use case), configDigest recurses into preferredManifestListArch's entry
+// instead of erroring out.
+func (p *ociProvider) configDigest(ctx context.Context, tag string) (string, error) {
+	return p.manifestConfigDigest(ctx, tag)
+}
+
+// manifestConfigDigest resolves the config blob digest for reference, a tag or a manifest digest
+// (the distribution v2 manifests endpoint accepts either). A manifest list/index has no config
+// digest of its own, so one more fetch is needed: pick whichever entry matches
+// preferredManifestListArch (falling back to the first entry if none does, rather than failing a
+// bump outright over an unlisted architecture) and recurse into its digest.
+func (p *ociProvider) manifestConfigDigest(ctx context.Context, reference string) (string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", p.registryURL, p.repo, reference)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", manifestAcceptHeader)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch manifest for %s:%s: %w", p.repo, reference, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch manifest for %s:%s: unexpected status %s", p.repo, reference, resp.Status)
+	}
+
+	var manifest struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return "", fmt.Errorf("failed to decode manifest for %s:%s: %w", p.repo, reference, err)
+	}
+
+	if manifest.Config.Digest != "" {
+		return manifest.Config.Digest, nil
+	}
+	if len(manifest.Manifests) == 0 {
+		return "", fmt.Errorf("manifest for %s:%s has no config digest", p.repo, reference)
+	}
+
+	entryDigest := manifest.Manifests[0].Digest
+	for _, entry := range manifest.Manifests {
+		if entry.Platform.Architecture == preferredManifestListArch {
+			entryDigest = entry.Digest
+			break
+		}
+	}
+	return p.manifestConfigDigest(ctx, entryDigest)
+}
@@ -0,0 +1,99 @@
+package bumper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestOCIProviderTagCreatedMultiArchManifestList guards against the bug where TagCreated only
+// understood single-arch manifests: a manifest list has no config digest of its own, so fetching
+// config.digest directly off it returned "manifest ... has no config digest" for every multi-arch
+// tag, breaking newestTag for OpenShift release-payload images (this provider's primary use case,
+// and always multi-arch). configDigest must instead pick an architecture's entry out of the list
+// and resolve its config digest.
+func TestOCIProviderTagCreatedMultiArchManifestList(t *testing.T) {
+	const (
+		repo       = "ocp/release"
+		tag        = "4.99.0-x86_64"
+		amd64Entry = "sha256:amd64entrydigest"
+		configBlob = "sha256:configblobdigest"
+	)
+	wantCreated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.list.v2+json")
+		fmt.Fprintf(w, `{
+			"schemaVersion": 2,
+			"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+			"manifests": [
+				{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "digest": "sha256:arm64entrydigest", "platform": {"architecture": "arm64", "os": "linux"}},
+				{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "digest": %q, "platform": {"architecture": "amd64", "os": "linux"}}
+			]
+		}`, amd64Entry)
+	})
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/manifests/%s", repo, amd64Entry), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+		fmt.Fprintf(w, `{
+			"schemaVersion": 2,
+			"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+			"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "digest": %q}
+		}`, configBlob)
+	})
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/blobs/%s", repo, configBlob), func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"created": %q}`, wantCreated.Format(time.RFC3339))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewOCIProvider(server.URL, repo, "https://github.com/openshift/release").(*ociProvider)
+	created, err := provider.TagCreated(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("TagCreated returned error: %v", err)
+	}
+	if !created.Equal(wantCreated) {
+		t.Fatalf("TagCreated = %v, want %v", created, wantCreated)
+	}
+}
+
+// TestOCIProviderTagCreatedSingleArchManifest guards the non-list path still works once
+// manifestConfigDigest also has to handle lists: a single-arch manifest's config digest should be
+// read directly, with no recursion into manifestConfigDigest.
+func TestOCIProviderTagCreatedSingleArchManifest(t *testing.T) {
+	const (
+		repo       = "team/app"
+		tag        = "v1"
+		configBlob = "sha256:configblobdigest"
+	)
+	wantCreated := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+		fmt.Fprintf(w, `{
+			"schemaVersion": 2,
+			"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+			"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "digest": %q}
+		}`, configBlob)
+	})
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/blobs/%s", repo, configBlob), func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"created": %q}`, wantCreated.Format(time.RFC3339))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewOCIProvider(server.URL, repo, "https://github.com/example/repo").(*ociProvider)
+	created, err := provider.TagCreated(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("TagCreated returned error: %v", err)
+	}
+	if !created.Equal(wantCreated) {
+		t.Fatalf("TagCreated = %v, want %v", created, wantCreated)
+	}
+}
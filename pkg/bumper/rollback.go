@@ -0,0 +1,156 @@
+package bumper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/robots/pr-creator/updater"
+)
+
+// revertCommitPattern matches the commit summary MakeGitCommit produces, e.g.
+// "Update prow to v20230101-abcdef12, and other images as necessary.", so RollbackReferences can
+// find the PRs it is reverting.
+var revertCommitPattern = regexp.MustCompile(`Update .* to ([a-zA-Z0-9_.-]+)`)
+
+// mergedPRNumberPattern matches the "(#1234)" suffix GitHub appends to a commit's subject line when
+// a PR is merged via the "Squash and merge" button, so FindRevertedPRs can link back to it.
+var mergedPRNumberPattern = regexp.MustCompile(`\(#(\d+)\)\s*$`)
+
+// RollbackReferences rewrites the image references under subfolders (and extraFiles) *backwards*
+// to targetTag, the inverse of UpdateReferences: every reference currently pointing at newTag is
+// rewritten to targetTag, and the reverse mapping (newTag -> targetTag) is recorded the same way
+// UpdateReferences records its replacements. filter and providers are composed the same way
+// NewBumper does (filter defaults to DefaultFilter(), providers further restrict it), so a
+// rollback can reach refs that were bumped through a custom Filter or a non-gcr.io
+// RegistryProvider, not just the original prowPrefix|testImagePrefix refs. targetTag itself can
+// come straight from an on-call-provided tag, or from ResolveRollbackTarget.
+func RollbackReferences(subfolders []string, extraFiles map[string]bool, targetTag string, filter Filter, providers []RegistryProvider) (map[string]string, error) {
+	return pinImageTags(context.Background(), subfolders, extraFiles, targetTag, filter, providers)
+}
+
+// ResolveRollbackTarget walks repoDir's git log for prior bump commits (matching
+// revertCommitPattern, i.e. the "Update .* to X" subjects MakeGitCommit produces) and returns the
+// tag that was in place before the last bumps bump commits, so a rollback can be driven by "undo
+// the last N bumps" instead of requiring on-call to know the exact historical tag to target.
+func ResolveRollbackTarget(repoDir string, bumps int) (string, error) {
+	if bumps < 1 {
+		return "", fmt.Errorf("bumps must be >= 1, got %d", bumps)
+	}
+
+	cmd := exec.Command("git", "-C", repoDir, "log", "--grep=Update .* to", "--extended-regexp", "--format=%s")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to search git log for prior bump commits: %w", err)
+	}
+
+	var tags []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := revertCommitPattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		tags = append(tags, m[1])
+	}
+	if bumps >= len(tags) {
+		return "", fmt.Errorf("found only %d prior bump commit(s) in %s, cannot roll back %d bump(s)", len(tags), repoDir, bumps)
+	}
+	return tags[bumps], nil
+}
+
+// RevertedPR identifies a prior bump PR that a rollback is undoing.
+type RevertedPR struct {
+	Number int
+	Title  string
+}
+
+// FindRevertedPRs scans `git log --grep` of prior bump commit messages in repoDir for commits that
+// bumped to any of the given tags, so the rollback PR body can link back to what it's undoing.
+func FindRevertedPRs(repoDir string, tags []string) ([]RevertedPR, error) {
+	var reverted []RevertedPR
+	for _, tag := range tags {
+		cmd := exec.Command("git", "-C", repoDir, "log", "--grep", tag, "--format=%s")
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search git log for %s: %w", tag, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			subject := scanner.Text()
+			if !revertCommitPattern.MatchString(subject) {
+				continue
+			}
+			pr := RevertedPR{Title: subject}
+			if m := mergedPRNumberPattern.FindStringSubmatch(subject); m != nil {
+				if n, err := strconv.Atoi(m[1]); err == nil {
+					pr.Number = n
+				}
+			}
+			reverted = append(reverted, pr)
+		}
+	}
+	return reverted, nil
+}
+
+// makeRevertCommitSummary builds the "Revert prow to X" title for a rollback PR, mirroring
+// makeCommitSummary's "Update prow to X" for a forward bump.
+func makeRevertCommitSummary(targetTag string) string {
+	return fmt.Sprintf("Revert prow to %s, and other images as necessary.", targetTag)
+}
+
+// generateRevertPRBody produces the same per-image compare sections as GeneratePRBody, clearly
+// labeled as a downgrade. images is already old reference -> new (rolled-back) bare tag, the same
+// convention RollbackReferences/generateSummary use for a forward bump, so it's passed through
+// as-is rather than inverted.
+func generateRevertPRBody(providers []RegistryProvider, images map[string]string, reverted []RevertedPR, extraLineInPRBody string) string {
+	sections := make([]string, 0, len(providers)+2)
+	sections = append(sections, "**This is a downgrade.**")
+	for _, provider := range providers {
+		sections = append(sections, generateSummary(provider, images))
+	}
+	if len(reverted) > 0 {
+		titles := make([]string, 0, len(reverted))
+		for _, pr := range reverted {
+			if pr.Number != 0 {
+				titles = append(titles, fmt.Sprintf("- #%d: %s", pr.Number, pr.Title))
+				continue
+			}
+			titles = append(titles, "- "+pr.Title)
+		}
+		sections = append(sections, "Reverts:\n"+strings.Join(titles, "\n"))
+	}
+	sections = append(sections, extraLineInPRBody)
+	return strings.Join(sections, "\n\n") + "\n"
+}
+
+// UpdateRollbackPR opens (or updates) the rollback PR through gc, with a "Revert " matchTitle
+// prefix so it doesn't collide with a forward-bump PR against the same branch.
+func UpdateRollbackPR(gc github.Client, org, repo string, providers []RegistryProvider, images map[string]string, reverted []RevertedPR, extraLineInPRBody, targetTag, source, branch string) error {
+	title := makeRevertCommitSummary(targetTag)
+	body := generateRevertPRBody(providers, images, reverted, extraLineInPRBody)
+	return updatePR(gc, org, repo, title, body, "Revert ", source, branch)
+}
+
+// updatePR mirrors bumper.go's own updatePR: it reuses updater.UpdatePR (the same PR
+// discovery/update bumper.go relies on for forward-bump PRs) to update a matching open PR, falling
+// back to creating a new one when updater.UpdatePR finds none.
+func updatePR(gc github.Client, org, repo, title, body, matchTitle, source, branch string) error {
+	n, err := updater.UpdatePR(org, repo, title, body, matchTitle, gc)
+	if err != nil {
+		return fmt.Errorf("failed to update PR: %w", err)
+	}
+	if n == nil {
+		if _, err := gc.CreatePullRequest(org, repo, title, body, source, branch, true); err != nil {
+			return fmt.Errorf("failed to create PR: %w", err)
+		}
+	}
+	return nil
+}
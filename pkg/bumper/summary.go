@@ -0,0 +1,78 @@
+package bumper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GeneratePRBody builds a PR body out of images (as returned by UpdateReferences), with one
+// section per provider so the same machinery can drive bumps against gcr.io, quay.io, or any OCI
+// registry providers in one pass.
+func GeneratePRBody(providers []RegistryProvider, images map[string]string, extraLineInPRBody string) string {
+	sections := make([]string, 0, len(providers)+1)
+	for _, provider := range providers {
+		sections = append(sections, generateSummary(provider, images))
+	}
+	sections = append(sections, extraLineInPRBody)
+	return strings.Join(sections, "\n\n") + "\n"
+}
+
+// generateSummary describes the images bumped under provider.Prefix(), grouping distinct
+// before/after commit pairs the same way the previous gcr.io-only implementation did.
+func generateSummary(provider RegistryProvider, images map[string]string) string {
+	type delta struct {
+		oldCommit, newCommit string
+		component            string
+	}
+	versions := map[string][]delta{}
+	for image, newTag := range images {
+		if !strings.HasPrefix(image, provider.Prefix()) {
+			continue
+		}
+		if strings.HasSuffix(image, ":"+newTag) {
+			continue
+		}
+		oldTag := tagFromName(image)
+		_, oldCommit, _, err := provider.ParseTag(oldTag)
+		if err != nil {
+			continue
+		}
+		_, newCommit, _, err := provider.ParseTag(newTag)
+		if err != nil {
+			continue
+		}
+		k := oldCommit + ":" + newCommit
+		versions[k] = append(versions[k], delta{oldCommit: oldCommit, newCommit: newCommit, component: componentFromName(image)})
+	}
+
+	name := provider.Prefix()
+	if len(versions) == 0 {
+		return fmt.Sprintf("No %s changes.", name)
+	}
+
+	changes := make([]string, 0, len(versions))
+	for _, v := range versions {
+		components := make([]string, 0, len(v))
+		for _, d := range v {
+			components = append(components, d.component)
+		}
+		sort.Strings(components)
+		changes = append(changes, fmt.Sprintf("%s | %s", provider.CompareURL(v[0].oldCommit, v[0].newCommit), strings.Join(components, ", ")))
+	}
+	sort.Strings(changes)
+	return fmt.Sprintf("%s changes:\n\nCompare | Images\n--- | ---\n%s", name, strings.Join(changes, "\n"))
+}
+
+func tagFromName(name string) string {
+	parts := strings.Split(name, ":")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+func componentFromName(name string) string {
+	s := strings.Split(strings.Split(name, ":")[0], "/")
+	return s[len(s)-1]
+}
@@ -3,8 +3,10 @@ package jobrunaggregatoranalyzer
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path"
+	"sort"
 
 	"gopkg.in/yaml.v2"
 
@@ -16,7 +18,23 @@ import (
 	"github.com/openshift/ci-tools/pkg/junit"
 )
 
-func (o *JobRunAggregatorAnalyzerOptions) CalculateDisruptionTestSuite(ctx context.Context, jobGCSBucketRoot string, finishedJobsToAggregate []jobrunaggregatorapi.JobRunInfo) (*junit.TestSuite, error) {
+const (
+	// disruptionEWMAAlpha is the smoothing factor for the EWMA baseline: each new sample moves
+	// the baseline 20% of the way towards it.
+	disruptionEWMAAlpha = 0.2
+	// disruptionEWMAThresholdK multiplies the baseline's MAD to get the allowed deviation above
+	// the EWMA before a cohort is considered regressed.
+	disruptionEWMAThresholdK = 3.0
+	// disruptionEWMAMinCohortFraction is the minimum fraction of individual runs in a cohort that
+	// must themselves be above the threshold before the check fails, so a single tail run doesn't
+	// flag the whole cohort.
+	disruptionEWMAMinCohortFraction = 0.3
+	// madConstant rescales the median absolute deviation to be comparable to a standard
+	// deviation for normally distributed data.
+	madConstant = 1.4826
+)
+
+func (o *JobRunAggregatorAnalyzerOptions) CalculateDisruptionTestSuite(ctx context.Context, jobGCSBucketRoot string, finishedJobsToAggregate []jobrunaggregatorapi.JobRunInfo, historicalJobRunsForBaseline []jobrunaggregatorapi.JobRunInfo) (*junit.TestSuite, error) {
 	disruptionJunitSuite := &junit.TestSuite{
 		Name:      "BackendDisruption",
 		TestCases: []*junit.TestCase{},
@@ -49,11 +67,22 @@ func (o *JobRunAggregatorAnalyzerOptions) CalculateDisruptionTestSuite(ctx conte
 		fmt.Fprintf(os.Stderr, "Could not fetch backend disruption data for all runs %v\n", err)
 	}
 
+	// The EWMA check needs a baseline computed from runs that predate the cohort it's judging, so
+	// fetch those separately rather than splitting the current cohort against itself.
+	historicalJobRunIDToBackendNameToAvailabilityResult, err := getDisruptionByJobRunID(ctx, historicalJobRunsForBaseline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not fetch historical backend disruption data for the EWMA baseline %v\n", err)
+	}
+
 	testCaseNamePatternToDisruptionCheckFn := map[string]disruptionJunitCheckFunc{
 		"%s mean disruption should be less than historical plus two standard deviations": o.passFailCalculator.CheckDisruptionMeanWithinTwoStandardDeviations,
 		// TODO add a SKIP mechanism to disruptionJunitCheckFunc instead of the fail bool
 		//"%s mean disruption should be less than historical plus one standard deviation":  o.passFailCalculator.CheckDisruptionMeanWithinOneStandardDeviation,
 		"%s disruption P95 should not be worse": o.passFailCalculator.CheckP95Disruption,
+		"%s disruption EWMA regression": func(ctx context.Context, jobRunIDToAvailabilityResultForBackend map[string]jobrunaggregatorlib.AvailabilityResult, backend string) ([]string, []string, bool, string, error) {
+			historicalForBackend := getDisruptionForBackend(historicalJobRunIDToBackendNameToAvailabilityResult, backend)
+			return checkDisruptionEWMARegression(ctx, jobRunIDToAvailabilityResultForBackend, historicalForBackend, backend)
+		},
 	}
 
 	for _, testCaseNamePattern := range sets.StringKeySet(testCaseNamePatternToDisruptionCheckFn).List() {
@@ -174,6 +203,99 @@ func getDisruptionForBackend(jobRunIDToBackendNameToAvailabilityResult map[strin
 	return jobRunIDToAvailabilityResultForBackend
 }
 
+// checkDisruptionEWMARegression flags backend when the current cohort's P95 disruption exceeds an
+// EWMA baseline (computed over historicalForBackend, runs that predate the cohort being judged,
+// alpha≈0.2) by more than disruptionEWMAThresholdK times the baseline's MAD, AND at least
+// disruptionEWMAMinCohortFraction of the individual runs in the current cohort are themselves
+// above that threshold, so a single tail run doesn't flag the whole cohort.
+//
+// Both the cohort and the baseline are ordered by sorting their job-run IDs lexicographically as a
+// stand-in for run-time order; this is only valid because Prow job-run IDs are monotonically
+// increasing decimal strings of the same length, so lexicographic and chronological order agree.
+func checkDisruptionEWMARegression(ctx context.Context, jobRunIDToAvailabilityResultForBackend, historicalForBackend map[string]jobrunaggregatorlib.AvailabilityResult, backend string) ([]string, []string, bool, string, error) {
+	jobRunIDs := sets.StringKeySet(jobRunIDToAvailabilityResultForBackend).List()
+	sort.Strings(jobRunIDs)
+
+	samples := make([]float64, 0, len(jobRunIDs))
+	for _, jobRunID := range jobRunIDs {
+		samples = append(samples, jobRunIDToAvailabilityResultForBackend[jobRunID].SecondsUnavailable)
+	}
+	if len(samples) == 0 {
+		return nil, jobRunIDs, false, fmt.Sprintf("no runs to evaluate an EWMA regression for %s", backend), nil
+	}
+
+	historicalJobRunIDs := sets.StringKeySet(historicalForBackend).List()
+	sort.Strings(historicalJobRunIDs)
+	baseline := make([]float64, 0, len(historicalJobRunIDs))
+	for _, jobRunID := range historicalJobRunIDs {
+		baseline = append(baseline, historicalForBackend[jobRunID].SecondsUnavailable)
+	}
+	if len(baseline) < 2 {
+		return nil, jobRunIDs, false, fmt.Sprintf("not enough historical runs to compute an EWMA baseline for %s", backend), nil
+	}
+
+	ewma := baseline[0]
+	for _, sample := range baseline[1:] {
+		ewma = disruptionEWMAAlpha*sample + (1-disruptionEWMAAlpha)*ewma
+	}
+	mad := medianAbsoluteDeviation(baseline)
+	threshold := ewma + disruptionEWMAThresholdK*mad
+
+	p95 := percentile(samples, 95)
+	aboveThreshold := 0
+	var failedJobRunIDs, successfulJobRunIDs []string
+	for _, jobRunID := range jobRunIDs {
+		if jobRunIDToAvailabilityResultForBackend[jobRunID].SecondsUnavailable > threshold {
+			aboveThreshold++
+			failedJobRunIDs = append(failedJobRunIDs, jobRunID)
+		} else {
+			successfulJobRunIDs = append(successfulJobRunIDs, jobRunID)
+		}
+	}
+
+	failed := p95 > threshold && float64(aboveThreshold)/float64(len(samples)) >= disruptionEWMAMinCohortFraction
+	message := fmt.Sprintf("%s disruption P95=%.1fs EWMA baseline=%.1fs threshold=%.1fs (%d/%d runs above threshold)",
+		backend, p95, ewma, threshold, aboveThreshold, len(samples))
+	if !failed {
+		failedJobRunIDs = nil
+		successfulJobRunIDs = jobRunIDs
+	}
+
+	return failedJobRunIDs, successfulJobRunIDs, failed, message, nil
+}
+
+func medianAbsoluteDeviation(samples []float64) float64 {
+	med := median(samples)
+	deviations := make([]float64, len(samples))
+	for i, s := range samples {
+		deviations[i] = math.Abs(s - med)
+	}
+	return median(deviations) * madConstant
+}
+
+func median(samples []float64) float64 {
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func percentile(samples []float64, p float64) float64 {
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
 func getAllDisruptionBackendNames(jobRunIDToBackendNameToAvailabilityResult map[string]map[string]jobrunaggregatorlib.AvailabilityResult) sets.String {
 	ret := sets.String{}
 	ret.Insert(jobrunaggregatorlib.RequiredDisruptionTests().List()...)
@@ -181,4 +303,4 @@ func getAllDisruptionBackendNames(jobRunIDToBackendNameToAvailabilityResult map[
 		ret.Insert(sets.StringKeySet(curr).List()...)
 	}
 	return ret
-}
\ No newline at end of file
+}
@@ -0,0 +1,149 @@
+package jobrunaggregatoranalyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/jobrunaggregator/jobrunaggregatorlib"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    float64
+	}{
+		{name: "odd count", samples: []float64{3, 1, 2}, want: 2},
+		{name: "even count", samples: []float64{1, 2, 3, 4}, want: 2.5},
+		{name: "single sample", samples: []float64{7}, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.samples); got != tt.want {
+				t.Errorf("median(%v) = %v, want %v", tt.samples, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedianAbsoluteDeviation(t *testing.T) {
+	// median is 10; absolute deviations are {1,0,1,2,0,1}, whose median is 1, scaled by
+	// madConstant.
+	samples := []float64{9, 10, 11, 12, 10, 9}
+	want := 1 * madConstant
+	if got := medianAbsoluteDeviation(samples); got != want {
+		t.Errorf("medianAbsoluteDeviation(%v) = %v, want %v", samples, got, want)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{p: 50, want: 50},
+		{p: 95, want: 100},
+		{p: 100, want: 100},
+		{p: 0, want: 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples, tt.p); got != tt.want {
+			t.Errorf("percentile(samples, %v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+// TestCheckDisruptionEWMARegression pins down the EWMA/MAD math against a fixed baseline and
+// cohort, guarding against the data-leakage bug f06d40c fixed (computing the baseline from the
+// same cohort it judges instead of from historical runs that predate it).
+func TestCheckDisruptionEWMARegression(t *testing.T) {
+	historical := map[string]jobrunaggregatorlib.AvailabilityResult{
+		"hist-1": {SecondsUnavailable: 10},
+		"hist-2": {SecondsUnavailable: 11},
+		"hist-3": {SecondsUnavailable: 9},
+		"hist-4": {SecondsUnavailable: 10},
+		"hist-5": {SecondsUnavailable: 12},
+		"hist-6": {SecondsUnavailable: 10},
+		"hist-7": {SecondsUnavailable: 11},
+	}
+
+	t.Run("regressed cohort fails", func(t *testing.T) {
+		cohort := map[string]jobrunaggregatorlib.AvailabilityResult{
+			"run-1": {SecondsUnavailable: 60},
+			"run-2": {SecondsUnavailable: 55},
+			"run-3": {SecondsUnavailable: 50},
+			"run-4": {SecondsUnavailable: 11},
+		}
+		failedJobRunIDs, successfulJobRunIDs, failed, _, err := checkDisruptionEWMARegression(context.Background(), cohort, historical, "test-backend")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !failed {
+			t.Fatalf("expected the cohort to be flagged as regressed")
+		}
+		if len(failedJobRunIDs) == 0 {
+			t.Errorf("expected at least one run to be reported as failed")
+		}
+		if len(successfulJobRunIDs)+len(failedJobRunIDs) != len(cohort) {
+			t.Errorf("failed+successful run IDs = %d, want %d", len(successfulJobRunIDs)+len(failedJobRunIDs), len(cohort))
+		}
+	})
+
+	t.Run("cohort within baseline passes", func(t *testing.T) {
+		cohort := map[string]jobrunaggregatorlib.AvailabilityResult{
+			"run-1": {SecondsUnavailable: 10},
+			"run-2": {SecondsUnavailable: 11},
+			"run-3": {SecondsUnavailable: 9},
+		}
+		_, successfulJobRunIDs, failed, _, err := checkDisruptionEWMARegression(context.Background(), cohort, historical, "test-backend")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if failed {
+			t.Fatalf("expected the cohort to pass, it did not")
+		}
+		if len(successfulJobRunIDs) != len(cohort) {
+			t.Errorf("successful run IDs = %d, want %d", len(successfulJobRunIDs), len(cohort))
+		}
+	})
+
+	t.Run("single tail run doesn't fail the whole cohort", func(t *testing.T) {
+		// Only 1 of 6 runs (~17%) is above threshold, below disruptionEWMAMinCohortFraction, so the
+		// regression must not fire even though one run spiked and, with six runs, the cohort's
+		// nearest-rank P95 is that spike itself and so is above threshold.
+		cohort := map[string]jobrunaggregatorlib.AvailabilityResult{
+			"run-1": {SecondsUnavailable: 10},
+			"run-2": {SecondsUnavailable: 11},
+			"run-3": {SecondsUnavailable: 9},
+			"run-4": {SecondsUnavailable: 10},
+			"run-5": {SecondsUnavailable: 11},
+			"run-6": {SecondsUnavailable: 90},
+		}
+		_, _, failed, _, err := checkDisruptionEWMARegression(context.Background(), cohort, historical, "test-backend")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if failed {
+			t.Fatalf("expected a single tail run not to flag the whole cohort")
+		}
+	})
+
+	t.Run("insufficient historical data is reported, not treated as a regression", func(t *testing.T) {
+		cohort := map[string]jobrunaggregatorlib.AvailabilityResult{
+			"run-1": {SecondsUnavailable: 10},
+		}
+		_, _, failed, message, err := checkDisruptionEWMARegression(context.Background(), cohort, map[string]jobrunaggregatorlib.AvailabilityResult{
+			"hist-1": {SecondsUnavailable: 10},
+		}, "test-backend")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if failed {
+			t.Fatalf("expected no regression to be reported without enough historical data")
+		}
+		if message == "" {
+			t.Errorf("expected a message explaining why the check was skipped")
+		}
+	})
+}